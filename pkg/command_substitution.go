@@ -0,0 +1,98 @@
+package dotenv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	commandSubstitutionDisabled     = errors.New("command substitution is disabled")
+	unterminatedCommandSubstitution = errors.New("unterminated command substitution")
+)
+
+// ParseOptions configures optional, opt-in parsing behavior for
+// ParseWithOptions.
+type ParseOptions struct {
+	// CommandRunner executes the shell-style command substitutions found in
+	// values, e.g. $(date +%F). Command substitution is refused with
+	// commandSubstitutionDisabled unless a CommandRunner is supplied, so
+	// callers must opt in to running commands found in a .env file.
+	CommandRunner func(cmd string) (string, error)
+}
+
+// substituteCommands expands every balanced, unescaped $(...) span in s by
+// invoking opts.CommandRunner with the enclosed command, trimming a single
+// trailing newline from its output. An escaped \$( is preserved literally
+// (with the backslash stripped) and never treated as a command.
+func substituteCommands(s string, opts ParseOptions) (string, error) {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], `\$(`):
+			sb.WriteString("$(")
+			i += 3
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '(':
+			cmd, end, ok := findBalancedParens(s, i+1)
+			if !ok {
+				return "", unterminatedCommandSubstitution
+			}
+			if opts.CommandRunner == nil {
+				return "", commandSubstitutionDisabled
+			}
+			out, err := opts.CommandRunner(cmd)
+			if err != nil {
+				return "", fmt.Errorf("command substitution %q failed: %w", cmd, err)
+			}
+			sb.WriteString(strings.TrimSuffix(out, "\n"))
+			i = end + 1
+		default:
+			sb.WriteByte(s[i])
+			i++
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// findBalancedParens returns the text enclosed by the parenthesis opening at
+// s[open] and its matching close, accounting for nested parentheses.
+func findBalancedParens(s string, open int) (inner string, end int, ok bool) {
+	depth := 1
+	for i := open + 1; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s[open+1 : i], i, true
+			}
+		}
+	}
+
+	return "", -1, false
+}
+
+// expand runs command substitution (when enabled) followed by parameter
+// expansion over line, resolving variables from envVars first and falling
+// back to the process environment.
+func expand(line string, envVars map[string]string, opts ParseOptions) (string, error) {
+	withCommands, err := substituteCommands(line, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return Expand(withCommands, fileThenEnvironLookup(envVars))
+}
+
+func fileThenEnvironLookup(envVars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		if val, ok := envVars[name]; ok {
+			return val, true
+		}
+		return os.LookupEnv(name)
+	}
+}
@@ -0,0 +1,61 @@
+package dotenv
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func stubCommandRunner(t *testing.T) func(cmd string) (string, error) {
+	t.Helper()
+	outputs := map[string]string{
+		"vault read -field=token secret/app": "s3cr3t\n",
+		"whoami":                             "eyad\n",
+	}
+
+	return func(cmd string) (string, error) {
+		out, ok := outputs[cmd]
+		if !ok {
+			return "", fmt.Errorf("stubCommandRunner: unexpected command %q", cmd)
+		}
+		return out, nil
+	}
+}
+
+func TestParseWithOptionsCommandSubstitution(t *testing.T) {
+	envFile, err := os.Open("../fixtures/valid/command_substitution.env")
+	assertNoError(t, err)
+	defer envFile.Close()
+
+	want := map[string]string{
+		"TOKEN":    "s3cr3t",
+		"GREETING": "hello eyad",
+		"ESCAPED":  "$(not a command)",
+	}
+
+	values, _, err := ParseWithOptions(envFile, ParseOptions{CommandRunner: stubCommandRunner(t)})
+	assertNoError(t, err)
+	assertMaps(t, values, want)
+}
+
+func TestParseWithOptionsCommandSubstitutionDisabledByDefault(t *testing.T) {
+	envFile, err := os.Open("../fixtures/valid/command_substitution.env")
+	assertNoError(t, err)
+	defer envFile.Close()
+
+	_, _, err = ParseWithOptions(envFile, ParseOptions{})
+	assertError(t, err, commandSubstitutionDisabled)
+}
+
+func TestFindBalancedParens(t *testing.T) {
+	inner, end, ok := findBalancedParens("$(echo $(whoami))", 1)
+	if !ok {
+		t.Fatal("expected balanced parens to be found")
+	}
+	if want := "echo $(whoami)"; inner != want {
+		t.Fatalf("expected inner %q but got %q", want, inner)
+	}
+	if want := len("$(echo $(whoami))") - 1; end != want {
+		t.Fatalf("expected end %d but got %d", want, end)
+	}
+}
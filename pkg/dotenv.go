@@ -1,14 +1,13 @@
 package dotenv
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -22,10 +21,19 @@ var (
 )
 
 var (
-	keyRegex           = regexp.MustCompile(`^[a-zA-Z_]+[a-zA-Z0-9_]*`)
-	substituteVarRegex = regexp.MustCompile(`(\\)?(\$)(\()?\{?([A-Z0-9_]+)?\}?`)
-	escapeRegex        = regexp.MustCompile(`\\.`)
-	unescapeRegex      = regexp.MustCompile(`\\([^$])`)
+	keyRegex               = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)(\[([^\]]*)\])?$`)
+	escapeRegex            = regexp.MustCompile(`\\.`)
+	unescapeRegex          = regexp.MustCompile(`\\([^$])`)
+	valueNeedsQuotingRegex = regexp.MustCompile("[\\\\\n\r\"!$`#]")
+	valueEscapeReplacer    = strings.NewReplacer(
+		`\`, `\\`,
+		"\n", `\n`,
+		"\r", `\r`,
+		`"`, `\"`,
+		`!`, `\!`,
+		"`", "\\`",
+		`$`, `\$`,
+	)
 )
 
 // Read reads the environment variables from the given files and returns them as a map.
@@ -37,14 +45,12 @@ func Read(filenames ...string) (map[string]string, error) {
 			return nil, fmt.Errorf("%w for file %s", inValidFileExtension, filename)
 		}
 
-		envFile, err := os.Open(filename)
+		data, err := os.ReadFile(filename)
 		if err != nil {
 			return nil, err
 		}
-		defer envFile.Close()
 
-		log.Println("reading file", filename)
-		vars, err := Parse(envFile)
+		vars, err := UnmarshalBytes(data)
 		if err != nil {
 			return nil, err
 		}
@@ -59,39 +65,69 @@ func Read(filenames ...string) (map[string]string, error) {
 
 // Parse parses the environment variables from the given file as a reader and returns them as a map.
 func Parse(envFile io.Reader) (map[string]string, error) {
-	envVars := make(map[string]string)
+	envVars, _, err := ParseWithModifiers(envFile)
+	return envVars, err
+}
 
-	scanner := bufio.NewScanner(envFile)
-	for scanner.Scan() {
-		line := scanner.Text()
+// ParseWithModifiers parses the environment variables from the given file as
+// a reader, same as Parse, but also recognizes an optional modifier block on
+// the key, e.g. PORT[int]=8080, DEBUG[bool]=true, HOSTS[array,sep=;]=a;b;c,
+// or SECRET[file]=/run/secrets/x. It returns the modifiers found for each
+// key as modifier name -> argument pairs, alongside the usual values map.
+func ParseWithModifiers(envFile io.Reader) (map[string]string, map[string]map[string]string, error) {
+	return ParseWithOptions(envFile, ParseOptions{})
+}
 
-		if strings.HasPrefix(line, "#") || len(strings.TrimSpace(line)) == 0 {
-			continue
-		}
+// ParseWithOptions parses the environment variables from the given file as a
+// reader, same as ParseWithModifiers, but lets callers opt into extra
+// parsing behavior through opts, such as command substitution.
+func ParseWithOptions(envFile io.Reader, opts ParseOptions) (map[string]string, map[string]map[string]string, error) {
+	data, err := io.ReadAll(envFile)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		line = strings.TrimPrefix(line, "export ")
-		keyVal := strings.SplitN(line, "=", 2)
+	envVars := make(map[string]string)
+	modifiers := make(map[string]map[string]string)
 
-		if len(keyVal) != 2 {
-			return nil, inValidLine
+	err = parseBytes(data, opts, func(key, value string, mods map[string]string) error {
+		envVars[key] = value
+		if mods != nil {
+			modifiers[key] = mods
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return envVars, modifiers, nil
+}
+
+// Load loads the environment variables from the given files into the current
+// environment, skipping any key that is already set. Use Overload to force
+// values from the files to take precedence instead.
+func Load(filenames ...string) error {
+	envVars, err := Read(filenames...)
+	if err != nil {
+		return err
+	}
 
-		key := strings.TrimSpace(keyVal[0])
-		if matched := keyRegex.MatchString(key); !matched {
-			return nil, fmt.Errorf("%w for %s", inValidKey, key)
+	for key, val := range envVars {
+		if _, exists := os.LookupEnv(key); exists {
+			continue
 		}
-		val, err := extractValue(keyVal[1], scanner, envVars)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse value for key %s %w", key, err)
+		if err := os.Setenv(key, val); err != nil {
+			return err
 		}
-
-		envVars[key] = val
 	}
-	return envVars, nil
+
+	return nil
 }
 
-// Load loads the environment variables from the given files into the current environment.
-func Load(filenames ...string) error {
+// Overload loads the environment variables from the given files into the
+// current environment, overwriting any key that is already set.
+func Overload(filenames ...string) error {
 	envVars, err := Read(filenames...)
 	if err != nil {
 		return err
@@ -106,9 +142,73 @@ func Load(filenames ...string) error {
 	return nil
 }
 
-func extractValue(val string, scanner *bufio.Scanner, currentEnvVars map[string]string) (string, error) {
+// Marshal serializes envMap into .env file format. Keys are sorted so the
+// output is deterministic, and values containing special characters are
+// double-quoted and escaped so that Unmarshal(Marshal(m)) reproduces m.
+func Marshal(envMap map[string]string) (string, error) {
+	keys := make([]string, 0, len(envMap))
+	for key := range envMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		sb.WriteString(key)
+		sb.WriteByte('=')
+		sb.WriteString(marshalValue(envMap[key]))
+		sb.WriteByte('\n')
+	}
+
+	return sb.String(), nil
+}
+
+// Unmarshal parses str as .env-formatted content and returns the resulting
+// environment variable map.
+func Unmarshal(str string) (map[string]string, error) {
+	return UnmarshalBytes([]byte(str))
+}
+
+// Write marshals envMap and writes it to filename, creating the file if it
+// doesn't exist or truncating it if it does.
+func Write(envMap map[string]string, filename string) error {
+	content, err := Marshal(envMap)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, []byte(content), 0644)
+}
+
+func marshalValue(val string) string {
+	if !valueNeedsQuoting(val) {
+		return val
+	}
+
+	return `"` + valueEscapeReplacer.Replace(val) + `"`
+}
+
+func valueNeedsQuoting(val string) bool {
+	if val == "" {
+		return false
+	}
+	if strings.TrimSpace(val) != val {
+		return true
+	}
+	if strings.HasPrefix(val, "'") || strings.HasPrefix(val, `"`) {
+		return true
+	}
+
+	return valueNeedsQuotingRegex.MatchString(val)
+}
+
+func extractValue(val string, scanner *lineReader, currentEnvVars map[string]string, opts ParseOptions) (string, error) {
 	if !strings.HasPrefix(val, "'") && !strings.HasPrefix(val, "\"") {
-		return parseEscape(substituteVariables(strings.TrimSpace(strings.Split(val, "#")[0]), currentEnvVars)), nil
+		expanded, err := expand(strings.TrimSpace(strings.Split(val, "#")[0]), currentEnvVars, opts)
+		if err != nil {
+			return "", err
+		}
+		return parseEscape(expanded), nil
 	}
 
 	var remaining string
@@ -133,7 +233,11 @@ func extractValue(val string, scanner *bufio.Scanner, currentEnvVars map[string]
 			line = strings.TrimSuffix(line, prefix)
 
 			if prefix == "\"\"\"" {
-				line = substituteVariables(line, currentEnvVars)
+				var err error
+				line, err = expand(line, currentEnvVars, opts)
+				if err != nil {
+					return "", err
+				}
 			}
 			multilineVal.WriteString(parseEscape(line) + "\n")
 		}
@@ -149,7 +253,7 @@ func extractValue(val string, scanner *bufio.Scanner, currentEnvVars map[string]
 		}
 		if strings.HasPrefix(val, string(prefix)) {
 			for i := 1; i < len(val); i++ {
-				if val[i] == prefix && !(i > 0 && val[i-1] == '\\') {
+				if val[i] == prefix && countTrailingBackslashes(val, i)%2 == 0 {
 					val, remaining = val[1:i], strings.TrimSpace(val[i+1:])
 				}
 			}
@@ -160,7 +264,11 @@ func extractValue(val string, scanner *bufio.Scanner, currentEnvVars map[string]
 		}
 
 		if prefix == '"' {
-			val = parseEscape(substituteVariables(val, currentEnvVars))
+			expanded, err := expand(val, currentEnvVars, opts)
+			if err != nil {
+				return "", err
+			}
+			val = parseEscape(expanded)
 		}
 	}
 
@@ -171,10 +279,54 @@ func extractValue(val string, scanner *bufio.Scanner, currentEnvVars map[string]
 	return val, nil
 }
 
+// splitKeyValue splits a line into its key and value parts on the first "="
+// that isn't part of a key's [...] modifier block, e.g. it splits
+// HOSTS[array,sep=;]=a;b;c after the closing "]" rather than at the "="
+// inside "sep=;". A "]" that only appears in the value, such as
+// ARR=[1,2] or PLAIN=value]here, is left alone.
+func splitKeyValue(line string) (key, value string, ok bool) {
+	eqIdx := strings.IndexByte(line, '=')
+	if eqIdx == -1 {
+		return "", "", false
+	}
+
+	keyPart := line[:eqIdx]
+	bracketOpen := strings.IndexByte(keyPart, '[')
+	bracketClose := strings.IndexByte(keyPart, ']')
+	if bracketOpen != -1 && bracketClose == -1 {
+		bracketEnd := strings.IndexByte(line[bracketOpen:], ']')
+		if bracketEnd == -1 {
+			return "", "", false
+		}
+		bracketEnd += bracketOpen
+
+		rest := strings.IndexByte(line[bracketEnd:], '=')
+		if rest == -1 {
+			return "", "", false
+		}
+		eqIdx = bracketEnd + rest
+	}
+
+	return line[:eqIdx], line[eqIdx+1:], true
+}
+
 func isMultiLine(line string) bool {
 	return strings.HasPrefix(line, "\"\"\"") || strings.HasPrefix(line, "'''")
 }
 
+// countTrailingBackslashes returns the number of consecutive backslash
+// characters immediately preceding val[idx], so callers can tell an escaped
+// quote (odd count) from a quote following an escaped backslash (even
+// count), e.g. in "a\\" the closing quote is preceded by two backslashes
+// and is not escaped.
+func countTrailingBackslashes(val string, idx int) int {
+	count := 0
+	for i := idx - 1; i >= 0 && val[i] == '\\'; i-- {
+		count++
+	}
+	return count
+}
+
 func parseEscape(str string) string {
 	out := escapeRegex.ReplaceAllStringFunc(str, func(match string) string {
 		c := strings.TrimPrefix(match, `\`)
@@ -195,12 +347,3 @@ func parseEscape(str string) string {
 	})
 	return unescapeRegex.ReplaceAllString(out, "$1")
 }
-
-func substituteVariables(line string, envVars map[string]string) string {
-	return substituteVarRegex.ReplaceAllStringFunc(line, func(match string) string {
-		if _, ok := envVars[match[2:len(match)-1]]; !ok {
-			return ""
-		}
-		return envVars[match[2:len(match)-1]]
-	})
-}
@@ -204,6 +204,226 @@ func TestLoad(t *testing.T) {
 	}
 }
 
+func TestParseWithModifiers(t *testing.T) {
+	wantValues := map[string]string{
+		"PORT":   "8080",
+		"DEBUG":  "true",
+		"HOSTS":  "a;b;c",
+		"SECRET": "/run/secrets/x",
+	}
+	wantModifiers := map[string]map[string]string{
+		"PORT":   {"int": ""},
+		"DEBUG":  {"bool": ""},
+		"HOSTS":  {"array": "", "sep": ";"},
+		"SECRET": {"file": ""},
+	}
+
+	envFile, err := os.Open("../fixtures/valid/modifiers.env")
+	assertNoError(t, err)
+	defer envFile.Close()
+
+	values, modifiers, err := ParseWithModifiers(envFile)
+	assertNoError(t, err)
+	assertMaps(t, values, wantValues)
+
+	if !reflect.DeepEqual(modifiers, wantModifiers) {
+		t.Fatalf("expected modifiers %v but got %v", wantModifiers, modifiers)
+	}
+}
+
+func TestParseWithModifiersUnknownModifier(t *testing.T) {
+	envFile, err := os.Open("../fixtures/invalid/unknown_modifier.env")
+	assertNoError(t, err)
+	defer envFile.Close()
+
+	_, _, err = ParseWithModifiers(envFile)
+	assertError(t, err, unknownModifier)
+}
+
+func TestOverload(t *testing.T) {
+	overloadEnvTests := []struct {
+		name     string
+		filename string
+		want     map[string]string
+		err      error
+	}{
+		{
+			name:     "invalid extension file",
+			filename: "../fixtures/invalid/invalid_extension.json",
+			want:     nil,
+			err:      inValidFileExtension,
+		},
+		{
+			name:     "valid file extension",
+			filename: "../fixtures/valid/comments.env",
+			want:     validCommentsMap,
+		},
+	}
+
+	for _, tt := range overloadEnvTests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("foo", "preexisting")
+
+			err := Overload(tt.filename)
+			assertError(t, err, tt.err)
+
+			for key, val := range tt.want {
+				if os.Getenv(key) != val {
+					t.Fatalf("expected %s=%s but got %s=%s", key, val, key, os.Getenv(key))
+				}
+			}
+
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+		})
+	}
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	marshalTests := []struct {
+		name   string
+		envMap map[string]string
+	}{
+		{
+			name: "simple values",
+			envMap: map[string]string{
+				"FOO": "bar",
+				"BAZ": "foo",
+			},
+		},
+		{
+			name: "values requiring quoting",
+			envMap: map[string]string{
+				"SPACED":    "with space",
+				"BACKSLASH": "back\\slash",
+				"NEWLINE":   "new\nline",
+				"DOLLAR":    "dollar$sign",
+				"BANG":      "bang!mark",
+				"QUOTE":     "quote\"in",
+				"TICK":      "tick`mark",
+				"HASH":      "hash#tag",
+				"EMPTY":     "",
+			},
+		},
+		{
+			name: "values ending in a backslash",
+			envMap: map[string]string{
+				"TRAILING": "a\\",
+				"WINPATH":  "C:\\path\\",
+			},
+		},
+	}
+
+	for _, tt := range marshalTests {
+		t.Run(tt.name, func(t *testing.T) {
+			marshaled, err := Marshal(tt.envMap)
+			assertNoError(t, err)
+
+			got, err := Unmarshal(marshaled)
+			assertNoError(t, err)
+			assertMaps(t, got, tt.envMap)
+		})
+	}
+}
+
+func TestMarshalIsDeterministic(t *testing.T) {
+	envMap := map[string]string{"B": "2", "A": "1", "C": "3"}
+
+	first, err := Marshal(envMap)
+	assertNoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		again, err := Marshal(envMap)
+		assertNoError(t, err)
+		if again != first {
+			t.Fatalf("expected deterministic output, got %q then %q", first, again)
+		}
+	}
+}
+
+func TestParseUTF8BOM(t *testing.T) {
+	envFile, err := os.Open("../fixtures/valid/bom.env")
+	assertNoError(t, err)
+	defer envFile.Close()
+
+	got, err := Parse(envFile)
+	assertNoError(t, err)
+	assertMaps(t, got, map[string]string{"FOO": "bar", "BAZ": "foo"})
+}
+
+func TestParseCRLFLineEndings(t *testing.T) {
+	envFile, err := os.Open("../fixtures/valid/crlf.env")
+	assertNoError(t, err)
+	defer envFile.Close()
+
+	got, err := Parse(envFile)
+	assertNoError(t, err)
+	assertMaps(t, got, map[string]string{
+		"FOO": "bar",
+		"BAZ": "quoted value",
+		"KEY": "line 1\nline 2",
+	})
+}
+
+func TestWrite(t *testing.T) {
+	envMap := map[string]string{"FOO": "bar", "BAZ": "with space"}
+	filename := t.TempDir() + "/out.env"
+
+	err := Write(envMap, filename)
+	assertNoError(t, err)
+
+	got, err := Read(filename)
+	assertNoError(t, err)
+	assertMaps(t, got, envMap)
+}
+
+func TestSplitKeyValue(t *testing.T) {
+	splitTests := []struct {
+		name      string
+		line      string
+		wantKey   string
+		wantValue string
+	}{
+		{
+			name:      "plain value containing a bracket",
+			line:      "PLAIN=value]here",
+			wantKey:   "PLAIN",
+			wantValue: "value]here",
+		},
+		{
+			name:      "array-like value not a modifier block",
+			line:      "ARR=[1,2]",
+			wantKey:   "ARR",
+			wantValue: "[1,2]",
+		},
+		{
+			name:      "url value containing brackets",
+			line:      "URL=http://x/[id]",
+			wantKey:   "URL",
+			wantValue: "http://x/[id]",
+		},
+		{
+			name:      "modifier block with = inside",
+			line:      "HOSTS[array,sep=;]=a;b;c",
+			wantKey:   "HOSTS[array,sep=;]",
+			wantValue: "a;b;c",
+		},
+	}
+
+	for _, tt := range splitTests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, value, ok := splitKeyValue(tt.line)
+			if !ok {
+				t.Fatalf("expected ok but got false")
+			}
+			if key != tt.wantKey || value != tt.wantValue {
+				t.Fatalf("expected key %q value %q but got key %q value %q", tt.wantKey, tt.wantValue, key, value)
+			}
+		})
+	}
+}
+
 func assertNoError(t *testing.T, err error) {
 	t.Helper()
 	if err != nil {
@@ -0,0 +1,161 @@
+package dotenv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	unterminatedExpansion   = errors.New("unterminated \"${\" expansion")
+	requiredVariableMissing = errors.New("required variable missing")
+)
+
+// expansionOperators are checked longest-first so that, e.g., ":-" is
+// recognized before its "-" suffix is mistaken for the no-colon operator.
+var expansionOperators = []string{":-", ":?", ":+", "-"}
+
+// Expand performs bash-style parameter expansion over s, resolving ${VAR},
+// ${VAR:-default} (default if unset or empty), ${VAR-default} (default only
+// if unset), ${VAR:?message} (fail if unset or empty) and ${VAR:+alt} (alt
+// if set) references, as well as bare $VAR references, using lookup to
+// resolve variable values. A backslash-escaped "\$" is emitted literally.
+// Default, alternate and message expressions are themselves expanded
+// recursively, so ${A:-${B:-fallback}} works as expected.
+func Expand(s string, lookup func(string) (string, bool)) (string, error) {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], `\$`):
+			sb.WriteByte('$')
+			i += 2
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			expr, end, ok := findBalancedBraces(s, i+1)
+			if !ok {
+				return "", fmt.Errorf("%w: %q", unterminatedExpansion, s[i:])
+			}
+			val, err := expandExpr(expr, lookup)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(val)
+			i = end + 1
+		case s[i] == '$':
+			name, next := readBareName(s, i+1)
+			if name == "" {
+				sb.WriteByte('$')
+				i++
+				continue
+			}
+			if val, ok := lookup(name); ok {
+				sb.WriteString(val)
+			}
+			i = next
+		default:
+			sb.WriteByte(s[i])
+			i++
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// findBalancedBraces returns the text enclosed by the brace opening at
+// s[open] and its matching close, accounting for nested ${...} references.
+func findBalancedBraces(s string, open int) (inner string, end int, ok bool) {
+	depth := 1
+	for i := open + 1; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return s[open+1 : i], i, true
+			}
+		}
+	}
+
+	return "", -1, false
+}
+
+func readBareName(s string, start int) (name string, end int) {
+	i := start
+	for i < len(s) && isNameByte(s[i]) {
+		i++
+	}
+	return s[start:i], i
+}
+
+func isNameByte(b byte) bool {
+	return b == '_' || (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9')
+}
+
+// expandExpr evaluates the content of a single ${...} reference.
+func expandExpr(expr string, lookup func(string) (string, bool)) (string, error) {
+	name, op, arg := splitExpansionExpr(expr)
+	value, isSet := lookup(name)
+
+	switch op {
+	case "":
+		return value, nil
+	case ":-":
+		if !isSet || value == "" {
+			return Expand(arg, lookup)
+		}
+		return value, nil
+	case "-":
+		if !isSet {
+			return Expand(arg, lookup)
+		}
+		return value, nil
+	case ":+":
+		if isSet && value != "" {
+			return Expand(arg, lookup)
+		}
+		return "", nil
+	case ":?":
+		if !isSet || value == "" {
+			msg, err := Expand(arg, lookup)
+			if err != nil {
+				return "", err
+			}
+			if msg == "" {
+				msg = name + ": parameter not set"
+			}
+			return "", fmt.Errorf("%w: %s", requiredVariableMissing, msg)
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+// splitExpansionExpr splits a ${...} expression into the variable name, the
+// operator (if any) and its argument, ignoring operator-like characters that
+// appear inside a nested ${...} reference within the argument.
+func splitExpansionExpr(expr string) (name, op, arg string) {
+	depth := 0
+
+	for i := 0; i < len(expr); i++ {
+		if strings.HasPrefix(expr[i:], "${") {
+			depth++
+			i++
+			continue
+		}
+		if expr[i] == '}' && depth > 0 {
+			depth--
+			continue
+		}
+		if depth == 0 {
+			for _, candidate := range expansionOperators {
+				if strings.HasPrefix(expr[i:], candidate) {
+					return expr[:i], candidate, expr[i+len(candidate):]
+				}
+			}
+		}
+	}
+
+	return expr, "", ""
+}
@@ -0,0 +1,51 @@
+package dotenv
+
+import (
+	"os"
+	"testing"
+)
+
+var validExpandMap = map[string]string{
+	"BASE":               "hello",
+	"EMPTY":              "",
+	"PLAIN":              "hello",
+	"WITH_DEFAULT_UNSET": "fallback",
+	"WITH_DEFAULT_EMPTY": "fallback",
+	"NO_COLON_DEFAULT":   "",
+	"WITH_ALT":           "alt",
+	"NESTED_DEFAULT":     "hello",
+}
+
+func TestParseExpandOperators(t *testing.T) {
+	envFile, err := os.Open("../fixtures/valid/expand.env")
+	assertNoError(t, err)
+	defer envFile.Close()
+
+	got, err := Parse(envFile)
+	assertNoError(t, err)
+	assertMaps(t, got, validExpandMap)
+}
+
+func TestParseExpandRequiredVariableMissing(t *testing.T) {
+	envFile, err := os.Open("../fixtures/invalid/expand_required_missing.env")
+	assertNoError(t, err)
+	defer envFile.Close()
+
+	_, err = Parse(envFile)
+	assertError(t, err, requiredVariableMissing)
+}
+
+func TestExpandNestedDefault(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "B" {
+			return "", false
+		}
+		return "", false
+	}
+
+	got, err := Expand("${A:-${B:-fallback}}", lookup)
+	assertNoError(t, err)
+	if got != "fallback" {
+		t.Fatalf("expected %q but got %q", "fallback", got)
+	}
+}
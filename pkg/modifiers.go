@@ -0,0 +1,132 @@
+package dotenv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	unknownModifier      = errors.New("unknown modifier")
+	unsupportedFieldType = errors.New("unsupported field type")
+)
+
+var knownModifiers = map[string]bool{
+	"int":   true,
+	"bool":  true,
+	"array": true,
+	"file":  true,
+}
+
+// parseModifiers splits a KEY[...] modifier block into modifier name ->
+// argument pairs. The first comma-separated segment names the modifier
+// (int, bool, array or file) and must be one of the known modifiers; any
+// further "name=value" segments are extra arguments for it, e.g. "sep=;" in
+// "array,sep=;".
+func parseModifiers(raw string) (map[string]string, error) {
+	parts := strings.Split(raw, ",")
+
+	name := parts[0]
+	if !knownModifiers[name] {
+		return nil, fmt.Errorf("%w: %s", unknownModifier, name)
+	}
+
+	mods := map[string]string{name: ""}
+	for _, part := range parts[1:] {
+		if key, value, ok := strings.Cut(part, "="); ok {
+			mods[key] = value
+		} else {
+			mods[part] = ""
+		}
+	}
+
+	return mods, nil
+}
+
+// Decode populates the exported fields of the struct pointed to by v from
+// the current process environment, using `env:"KEY"` struct tags to pick the
+// source variable for each field. Values are coerced to the field's Go type
+// (int, bool or []string). modifiers is the per-key modifier map returned by
+// ParseWithModifiers: a key carrying a "file" modifier, e.g. from
+// SECRET[file]=/run/secrets/x, has its environment value read as a path and
+// replaced with the referenced file's contents before coercion, and a key
+// carrying an array modifier's "sep" argument, e.g. from
+// HOSTS[array,sep=;]=a;b;c, splits its slice on that separator in preference
+// to the field's "envSep" tag, which is only consulted when no modifier sep
+// is present. Falls back to "," if neither is set. Pass nil if none of the
+// decoded keys use modifiers.
+func Decode(v any, modifiers map[string]map[string]string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("dotenv: Decode requires a non-nil pointer to a struct")
+	}
+
+	elem := rv.Elem()
+	t := elem.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		key, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+
+		raw, present := os.LookupEnv(key)
+		if !present {
+			continue
+		}
+
+		if _, isFile := modifiers[key]["file"]; isFile {
+			content, err := os.ReadFile(raw)
+			if err != nil {
+				return fmt.Errorf("failed to load file for key %s: %w", key, err)
+			}
+			raw = strings.TrimRight(string(content), "\n")
+		}
+
+		if err := decodeField(elem.Field(i), raw, field.Tag, modifiers[key]); err != nil {
+			return fmt.Errorf("failed to decode key %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func decodeField(fv reflect.Value, raw string, tag reflect.StructTag, mods map[string]string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%w: %s", unsupportedFieldType, fv.Type())
+		}
+		sep := mods["sep"]
+		if sep == "" {
+			sep = tag.Get("envSep")
+		}
+		if sep == "" {
+			sep = ","
+		}
+		fv.Set(reflect.ValueOf(strings.Split(raw, sep)))
+	default:
+		return fmt.Errorf("%w: %s", unsupportedFieldType, fv.Kind())
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package dotenv
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	secretPath := filepath.Join(t.TempDir(), "secret.txt")
+	assertNoError(t, os.WriteFile(secretPath, []byte("s3cr3t\n"), 0644))
+
+	os.Setenv("PORT", "8080")
+	os.Setenv("DEBUG", "true")
+	os.Setenv("HOSTS", "a;b;c")
+	os.Setenv("SECRET", secretPath)
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	modifiers := map[string]map[string]string{
+		"HOSTS":  {"array": "", "sep": ";"},
+		"SECRET": {"file": ""},
+	}
+
+	type config struct {
+		Port   int      `env:"PORT"`
+		Debug  bool     `env:"DEBUG"`
+		Hosts  []string `env:"HOSTS" envSep:";"`
+		Secret string   `env:"SECRET"`
+	}
+
+	var cfg config
+	err := Decode(&cfg, modifiers)
+	assertNoError(t, err)
+
+	want := config{
+		Port:   8080,
+		Debug:  true,
+		Hosts:  []string{"a", "b", "c"},
+		Secret: "s3cr3t",
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("expected %+v but got %+v", want, cfg)
+	}
+}
+
+func TestDecodeUsesModifierMapFromParseWithModifiers(t *testing.T) {
+	secretPath := filepath.Join(t.TempDir(), "secret.txt")
+	assertNoError(t, os.WriteFile(secretPath, []byte("s3cr3t\n"), 0644))
+
+	envFile, err := os.Open("../fixtures/valid/modifiers.env")
+	assertNoError(t, err)
+	defer envFile.Close()
+
+	values, modifiers, err := ParseWithModifiers(envFile)
+	assertNoError(t, err)
+
+	values["SECRET"] = secretPath
+	for key, val := range values {
+		os.Setenv(key, val)
+	}
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	type config struct {
+		Port   int      `env:"PORT"`
+		Debug  bool     `env:"DEBUG"`
+		Hosts  []string `env:"HOSTS"`
+		Secret string   `env:"SECRET"`
+	}
+
+	var cfg config
+	err = Decode(&cfg, modifiers)
+	assertNoError(t, err)
+
+	want := config{
+		Port:   8080,
+		Debug:  true,
+		Hosts:  []string{"a", "b", "c"},
+		Secret: "s3cr3t",
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("expected %+v but got %+v", want, cfg)
+	}
+}
+
+func TestDecodeArraySepModifierTakesPrecedenceOverEnvSepTag(t *testing.T) {
+	os.Setenv("HOSTS", "a;b;c")
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	modifiers := map[string]map[string]string{
+		"HOSTS": {"array": "", "sep": ";"},
+	}
+
+	type config struct {
+		Hosts []string `env:"HOSTS" envSep:","`
+	}
+
+	var cfg config
+	err := Decode(&cfg, modifiers)
+	assertNoError(t, err)
+
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(cfg.Hosts, want) {
+		t.Fatalf("expected %v but got %v", want, cfg.Hosts)
+	}
+}
+
+func TestDecodeRequiresStructPointer(t *testing.T) {
+	var notAStruct int
+	if err := Decode(&notAStruct, nil); err == nil {
+		t.Fatal("expected error for non-struct pointer")
+	}
+	if err := Decode(struct{}{}, nil); err == nil {
+		t.Fatal("expected error for non-pointer")
+	}
+}
@@ -0,0 +1,143 @@
+package dotenv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// lineReader splits a []byte into successive lines on demand, the same way
+// bufio.Scanner with bufio.ScanLines does, but without its 64KiB token cap
+// so a single very large multiline value (e.g. a PEM block) never gets
+// truncated or fails to parse.
+type lineReader struct {
+	remaining []byte
+	line      []byte
+}
+
+func newLineReader(data []byte) *lineReader {
+	return &lineReader{remaining: data}
+}
+
+// Scan advances to the next line, reporting whether one was found.
+func (r *lineReader) Scan() bool {
+	if r.remaining == nil {
+		return false
+	}
+
+	before, after, found := bytes.Cut(r.remaining, []byte{'\n'})
+	r.line = bytes.TrimSuffix(before, []byte{'\r'})
+
+	if !found {
+		r.remaining = nil
+		return true
+	}
+
+	if after == nil {
+		// Keep scanning past a trailing newline so a subsequent Scan still
+		// reports one final, empty line before stopping.
+		after = []byte{}
+	}
+	r.remaining = after
+	return true
+}
+
+func (r *lineReader) Text() string {
+	return string(r.line)
+}
+
+// utf8BOM is the UTF-8 byte order mark, which some editors (notably on
+// Windows) write at the start of a file even though UTF-8 doesn't need one.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// trimBOM strips a leading UTF-8 byte order mark from data, if present, so
+// it doesn't end up prepended to the first key.
+func trimBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, utf8BOM)
+}
+
+// parseBytes walks data line by line and invokes handleEntry for each
+// key/value assignment as soon as it is parsed, along with any modifiers
+// found on its key. It keeps its own running map of values internally,
+// since later assignments may reference earlier ones via variable
+// expansion, but never accumulates them for the caller.
+func parseBytes(data []byte, opts ParseOptions, handleEntry func(key, value string, mods map[string]string) error) error {
+	envVars := make(map[string]string)
+	lr := newLineReader(trimBOM(data))
+
+	for lr.Scan() {
+		line := lr.Text()
+
+		if strings.HasPrefix(line, "#") || len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		rawKey, rawVal, ok := splitKeyValue(line)
+		if !ok {
+			return inValidLine
+		}
+
+		rawKey = strings.TrimSpace(rawKey)
+		match := keyRegex.FindStringSubmatch(rawKey)
+		if match == nil {
+			return fmt.Errorf("%w for %s", inValidKey, rawKey)
+		}
+
+		key := match[1]
+		var mods map[string]string
+		if modifierText := match[3]; modifierText != "" {
+			m, err := parseModifiers(modifierText)
+			if err != nil {
+				return fmt.Errorf("failed to parse modifiers for key %s %w", key, err)
+			}
+			mods = m
+		}
+
+		val, err := extractValue(rawVal, lr, envVars, opts)
+		if err != nil {
+			return fmt.Errorf("failed to parse value for key %s %w", key, err)
+		}
+
+		envVars[key] = val
+		if err := handleEntry(key, val, mods); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalBytes parses data as .env-formatted content and returns the
+// resulting environment variable map. It's the []byte counterpart of
+// Unmarshal, and what Unmarshal, Parse and Read are built on.
+func UnmarshalBytes(data []byte) (map[string]string, error) {
+	envVars := make(map[string]string)
+
+	err := parseBytes(data, ParseOptions{}, func(key, value string, _ map[string]string) error {
+		envVars[key] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return envVars, nil
+}
+
+// ParseStream parses envFile as .env-formatted content and invokes fn for
+// each key/value assignment as soon as it's parsed, instead of building the
+// full map. This lets callers filter, transform or forward entries (e.g.
+// writing directly into a `docker run --env` list) in O(1) memory relative
+// to the number of entries, regardless of the file's size.
+func ParseStream(envFile io.Reader, fn func(key, value string) error) error {
+	data, err := io.ReadAll(envFile)
+	if err != nil {
+		return err
+	}
+
+	return parseBytes(data, ParseOptions{}, func(key, value string, _ map[string]string) error {
+		return fn(key, value)
+	})
+}
@@ -0,0 +1,66 @@
+package dotenv
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalBytes(t *testing.T) {
+	got, err := UnmarshalBytes([]byte("FOO=bar\nBAZ=foo\n"))
+	assertNoError(t, err)
+	assertMaps(t, got, map[string]string{"FOO": "bar", "BAZ": "foo"})
+}
+
+func TestParseStream(t *testing.T) {
+	envFile, err := os.Open("../fixtures/valid/quoted.env")
+	assertNoError(t, err)
+	defer envFile.Close()
+
+	got := make(map[string]string)
+	err = ParseStream(envFile, func(key, value string) error {
+		got[key] = value
+		return nil
+	})
+	assertNoError(t, err)
+	assertMaps(t, got, validQuotedMap)
+}
+
+func TestParseStreamStopsOnCallbackError(t *testing.T) {
+	wantErr := errors.New("stop")
+
+	err := ParseStream(strings.NewReader("FOO=bar\nBAZ=foo\n"), func(key, value string) error {
+		return wantErr
+	})
+	assertError(t, err, wantErr)
+}
+
+// TestParseLargeMultilineValue exercises a multiline value well over the
+// 64KiB token size that bufio.Scanner imposes by default, to guard against
+// regressing back onto it.
+func TestParseLargeMultilineValue(t *testing.T) {
+	envFile, err := os.Open("../fixtures/valid/large_multiline.env")
+	assertNoError(t, err)
+	defer envFile.Close()
+
+	got, err := Parse(envFile)
+	assertNoError(t, err)
+
+	if len(got["KEY"]) < 64*1024 {
+		t.Fatalf("expected KEY value over 64KiB, got %d bytes", len(got["KEY"]))
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("FOO=bar\n")
+	f.Add("export FOO=bar\nBAZ=\"with space\"\n")
+	f.Add("KEY=\"\"\"\nline one\nline two\n\"\"\"\n")
+	f.Add("PORT[int]=8080\n")
+	f.Add("# comment\nFOO=${BAR:-default}\n")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		// Parse must never panic, regardless of the error it returns.
+		_, _ = UnmarshalBytes([]byte(data))
+	})
+}